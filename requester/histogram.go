@@ -0,0 +1,153 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requester
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histogram is a minimal HDR-style (logarithmic bucket) latency histogram.
+// It trades exact values for a fixed relative error (sigFigs significant
+// decimal digits) so that percentiles like p99.9/p99.99 stay accurate across
+// millions of samples without keeping every sample in memory, which matters
+// for the open-model arrival scheduler where coordinated omission must not
+// be re-introduced by the reporting path.
+type histogram struct {
+	unitMagnitude               int
+	subBucketHalfCountMagnitude int
+	subBucketCount              int
+	subBucketHalfCount          int
+	subBucketMask               int64
+	lowestDiscernible           int64
+	highestTrackable            int64
+	counts                      []int64
+	totalCount                  int64
+}
+
+// newHistogram builds a histogram covering [lowestDiscernible, highestTrackable]
+// with sigFigs significant decimal digits of relative precision. Latencies
+// are recorded and read back as time.Duration; 1µs to 60s with 3 sigFigs is
+// the range used for reported request latency.
+func newHistogram(lowestDiscernible, highestTrackable time.Duration, sigFigs int) *histogram {
+	low := int64(lowestDiscernible)
+	if low < 1 {
+		low = 1
+	}
+	high := int64(highestTrackable)
+
+	largestValueWithSingleUnitResolution := 2 * math.Pow10(sigFigs)
+	subBucketCountMagnitude := int(math.Ceil(math.Log2(largestValueWithSingleUnitResolution)))
+	subBucketHalfCountMagnitude := subBucketCountMagnitude - 1
+	if subBucketHalfCountMagnitude < 0 {
+		subBucketHalfCountMagnitude = 0
+	}
+	unitMagnitude := int(math.Floor(math.Log2(float64(low))))
+	if unitMagnitude < 0 {
+		unitMagnitude = 0
+	}
+	subBucketCount := 1 << uint(subBucketHalfCountMagnitude+1)
+	subBucketHalfCount := subBucketCount / 2
+	subBucketMask := int64(subBucketCount-1) << uint(unitMagnitude)
+
+	h := &histogram{
+		unitMagnitude:               unitMagnitude,
+		subBucketHalfCountMagnitude: subBucketHalfCountMagnitude,
+		subBucketCount:              subBucketCount,
+		subBucketHalfCount:          subBucketHalfCount,
+		subBucketMask:               subBucketMask,
+		lowestDiscernible:           low,
+		highestTrackable:            high,
+	}
+
+	bucketsNeeded := 1
+	smallestUntrackable := int64(subBucketCount) << uint(unitMagnitude)
+	for smallestUntrackable <= high {
+		if smallestUntrackable > math.MaxInt64/2 {
+			bucketsNeeded++
+			break
+		}
+		smallestUntrackable <<= 1
+		bucketsNeeded++
+	}
+	countsLen := (bucketsNeeded + 1) * (subBucketHalfCount)
+	h.counts = make([]int64, countsLen)
+	return h
+}
+
+func (h *histogram) bucketIndex(v int64) int {
+	pow2Ceiling := bits.Len64(uint64(v | h.subBucketMask))
+	return pow2Ceiling - h.unitMagnitude - (h.subBucketHalfCountMagnitude + 1)
+}
+
+func (h *histogram) subBucketIndex(v int64, bucketIdx int) int {
+	return int(v >> uint(bucketIdx+h.unitMagnitude))
+}
+
+func (h *histogram) countsIndex(bucketIdx, subBucketIdx int) int {
+	bucketBaseIndex := (bucketIdx + 1) << uint(h.subBucketHalfCountMagnitude)
+	offsetInBucket := subBucketIdx - h.subBucketHalfCount
+	return bucketBaseIndex + offsetInBucket
+}
+
+// record adds d to the histogram, clamping to the tracked range.
+func (h *histogram) record(d time.Duration) {
+	v := int64(d)
+	if v < h.lowestDiscernible {
+		v = h.lowestDiscernible
+	}
+	if v > h.highestTrackable {
+		v = h.highestTrackable
+	}
+	bucketIdx := h.bucketIndex(v)
+	subBucketIdx := h.subBucketIndex(v, bucketIdx)
+	idx := h.countsIndex(bucketIdx, subBucketIdx)
+	if idx < 0 || idx >= len(h.counts) {
+		return
+	}
+	h.counts[idx]++
+	h.totalCount++
+}
+
+func (h *histogram) valueFromIndex(idx int) int64 {
+	bucketIdx := (idx >> uint(h.subBucketHalfCountMagnitude)) - 1
+	subBucketIdx := (idx & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucketIdx < 0 {
+		subBucketIdx -= h.subBucketHalfCount
+		bucketIdx = 0
+	}
+	return int64(subBucketIdx) << uint(bucketIdx+h.unitMagnitude)
+}
+
+// valueAtPercentile returns the latency at the given percentile (0-100),
+// e.g. 99.9 for p99.9, as a time.Duration.
+func (h *histogram) valueAtPercentile(percentile float64) time.Duration {
+	if h.totalCount == 0 {
+		return 0
+	}
+	if percentile > 100 {
+		percentile = 100
+	}
+	target := int64(math.Ceil(percentile / 100 * float64(h.totalCount)))
+	var cumulative int64
+	for i, c := range h.counts {
+		cumulative += c
+		if cumulative >= target {
+			return time.Duration(h.valueFromIndex(i))
+		}
+	}
+	return time.Duration(h.highestTrackable)
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requester
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := newHistogram(time.Microsecond, 60*time.Second, 3)
+	for ms := 1; ms <= 1000; ms++ {
+		h.record(time.Duration(ms) * time.Millisecond)
+	}
+
+	cases := []struct {
+		percentile float64
+		want       time.Duration
+		tolerance  time.Duration
+	}{
+		{50, 500 * time.Millisecond, 5 * time.Millisecond},
+		{90, 900 * time.Millisecond, 9 * time.Millisecond},
+		{99, 990 * time.Millisecond, 10 * time.Millisecond},
+		{99.9, 999 * time.Millisecond, 10 * time.Millisecond},
+	}
+	for _, c := range cases {
+		got := h.valueAtPercentile(c.percentile)
+		diff := got - c.want
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > c.tolerance {
+			t.Errorf("valueAtPercentile(%v) = %v, want within %v of %v", c.percentile, got, c.tolerance, c.want)
+		}
+	}
+}
+
+func TestHistogramClampsOutOfRange(t *testing.T) {
+	h := newHistogram(time.Millisecond, time.Second, 3)
+	h.record(0)
+	h.record(time.Hour)
+	if h.totalCount != 2 {
+		t.Fatalf("totalCount = %d, want 2", h.totalCount)
+	}
+	if got := h.valueAtPercentile(100); time.Duration(got) > time.Second {
+		t.Errorf("valueAtPercentile(100) = %v, want <= highestTrackable (1s)", got)
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := newHistogram(time.Microsecond, 60*time.Second, 3)
+	if got := h.valueAtPercentile(99); got != 0 {
+		t.Errorf("valueAtPercentile on empty histogram = %v, want 0", got)
+	}
+}
@@ -22,17 +22,23 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptrace"
 	"net/url"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.melnyk.org/heya/scenario"
 	"go.melnyk.org/mansi"
 	"go.melnyk.org/spinner"
 	"golang.org/x/net/http2"
+	"golang.org/x/net/publicsuffix"
 )
 
 // Max size of the buffer of result channel.
@@ -40,18 +46,43 @@ const maxResult = 32000000
 const maxIdleConn = 500
 
 type result struct {
-	err           error
-	statusCode    int
-	offset        time.Duration
-	duration      time.Duration
-	connDuration  time.Duration // connection setup(DNS lookup + Dial up) duration
-	dnsDuration   time.Duration // dns lookup duration
-	reqDuration   time.Duration // request "write" duration
-	resDuration   time.Duration // response "read" duration
-	delayDuration time.Duration // delay between response and request
-	contentLength int64
+	err              error
+	statusCode       int
+	offset           time.Duration
+	duration         time.Duration
+	connDuration     time.Duration // connection setup(DNS lookup + Dial up) duration
+	dnsDuration      time.Duration // dns lookup duration
+	reqDuration      time.Duration // request "write" duration
+	resDuration      time.Duration // response "read" duration
+	delayDuration    time.Duration // delay between response and request
+	contentLength    int64
+	scheduledOffset  time.Duration // intended start time under the open model, relative to b.start
+	tlsDuration      time.Duration // TLS handshake duration, when a new connection is dialed
+	continueDuration time.Duration // time spent waiting on "100 Continue" after an Expect header
+	stepName         string        // scenario step name, set when Work.Scenario is used
+	validationErr    error         // non-nil when Work.Validate rejected a successful response
 }
 
+// ArrivalModel selects how requests are scheduled over time.
+type ArrivalModel int
+
+const (
+	// Closed is the default, closed-workload model: C workers each pull
+	// requests back-to-back (optionally throttled by QPS), so the offered
+	// load drops whenever the server slows down.
+	Closed ArrivalModel = iota
+
+	// PoissonOpen schedules request start times at inter-arrival intervals
+	// drawn from a Poisson process with rate QPS, independent of how fast
+	// workers drain them. This avoids coordinated omission: a slow server
+	// shows up as growing latency, not as reduced offered load.
+	PoissonOpen
+
+	// ConstantOpen is like PoissonOpen but uses fixed inter-arrival
+	// intervals (1/QPS) instead of exponentially distributed ones.
+	ConstantOpen
+)
+
 type Work struct {
 	// Request is the request to be made.
 	Request *http.Request
@@ -62,6 +93,12 @@ type Work struct {
 	// Request and RequestData are cloned for each request.
 	RequestFunc func() *http.Request
 
+	// Scenario, if set, is used instead of Request/RequestFunc to produce
+	// each request, e.g. a weighted mix of endpoints or a replayed HAR
+	// file. Each result is tagged with the scenario step name, and request
+	// and error counts are printed per step name at the end of the run.
+	Scenario scenario.Scenario
+
 	// N is the total number of requests to make.
 	N int
 
@@ -74,6 +111,46 @@ type Work struct {
 	// H2 is an option to make HTTP/2 requests
 	H2 bool
 
+	// H2ReadIdleTimeout is the interval after which, if no frame has been
+	// received, an HTTP/2 PING is sent as an active health check. Zero
+	// disables health checking, matching http2.Transport's own default.
+	H2ReadIdleTimeout time.Duration
+
+	// H2PingTimeout is how long to wait for a PING ack before the HTTP/2
+	// connection is considered dead and closed. Zero means http2.Transport's
+	// default (15s).
+	H2PingTimeout time.Duration
+
+	// H2StrictMaxStreams controls whether the server's
+	// SETTINGS_MAX_CONCURRENT_STREAMS is respected as a single global limit
+	// (true, callers block when the limit is reached) or per-connection,
+	// opening additional connections as needed to stay under it (false, the
+	// historical behavior).
+	H2StrictMaxStreams bool
+
+	// H2MaxHeaderListSize caps the SETTINGS_MAX_HEADER_LIST_SIZE advertised
+	// to the server. Zero uses http2.Transport's default.
+	H2MaxHeaderListSize uint32
+
+	// H2MaxReadFrameSize caps the SETTINGS_MAX_FRAME_SIZE advertised to the
+	// server. Zero uses http2.Transport's default.
+	H2MaxReadFrameSize uint32
+
+	// H2OneConnPerWorker dials one dedicated *http2.ClientConn per worker
+	// instead of letting requests spread across the shared connection pool.
+	// Combined with H2MaxConcurrentStreams, this is the only way to make a
+	// worker's requests genuinely contend for one connection's stream
+	// budget, which is what surfaces per-connection head-of-line blocking.
+	// It applies to the Closed arrival model only; under PoissonOpen and
+	// ConstantOpen every worker uses the shared connection pool.
+	H2OneConnPerWorker bool
+
+	// H2MaxConcurrentStreams caps how many requests a worker running under
+	// H2OneConnPerWorker will have in flight at once on its dedicated
+	// connection. Only meaningful when H2OneConnPerWorker is true; a value
+	// less than 1 is treated as 1.
+	H2MaxConcurrentStreams uint32
+
 	// TLSResume is used to decide whether TLS session resumption is enabled between requests
 	TLSResume bool
 
@@ -83,6 +160,14 @@ type Work struct {
 	// Qps is the rate limit in queries per second.
 	QPS float64
 
+	// Arrival selects the workload arrival process. The zero value, Closed,
+	// preserves the historical C-workers-pulling-work behavior. PoissonOpen
+	// and ConstantOpen require QPS to be set: they schedule requests from a
+	// single generator goroutine and report latency relative to each
+	// request's intended start time rather than when a worker actually
+	// picked it up.
+	Arrival ArrivalModel
+
 	// DisableCompression is an option to disable compression in response
 	DisableCompression bool
 
@@ -100,6 +185,68 @@ type Work struct {
 	// Optional.
 	ProxyAddr *url.URL
 
+	// DialTimeout is the maximum amount of time a dial will wait for a
+	// connect to complete. Zero means the http.DefaultTransport default (30s).
+	DialTimeout time.Duration
+
+	// KeepAlive is the interval between keep-alive probes on the dialed
+	// connection. Zero means the http.DefaultTransport default (30s).
+	KeepAlive time.Duration
+
+	// TLSHandshakeTimeout is the maximum amount of time to wait for a TLS
+	// handshake. Zero means the http.DefaultTransport default (10s).
+	TLSHandshakeTimeout time.Duration
+
+	// ExpectContinueTimeout is the maximum amount of time to wait for a
+	// server's first response headers after fully writing the request
+	// headers, when the request has an "Expect: 100-continue" header. Zero
+	// means the http.DefaultTransport default (1s).
+	ExpectContinueTimeout time.Duration
+
+	// ResponseHeaderTimeout, if non-zero, is the maximum amount of time to
+	// wait for a server's response headers after fully writing the request.
+	ResponseHeaderTimeout time.Duration
+
+	// IdleConnTimeout is the maximum amount of time an idle (keep-alive)
+	// connection is kept in the pool before being closed. Zero means the
+	// http.DefaultTransport default (90s).
+	IdleConnTimeout time.Duration
+
+	// DialContext, if set, is used in place of the transport's default
+	// dialer, e.g. to dial a Unix-domain socket or force a specific
+	// source IP.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// UseCookieJar gives each worker its own cookiejar.Jar, installed on
+	// that worker's *http.Client before it starts issuing requests. This
+	// makes stateful flows (login, CSRF, sticky sessions) work: when
+	// RequestFunc produces a login response carrying Set-Cookie headers,
+	// subsequent requests made by the same worker will carry the resulting
+	// cookies. Jars are never shared across workers, so one worker's
+	// cookies can't leak into another's requests.
+	UseCookieJar bool
+
+	// InitialCookies seeds every worker's cookie jar before any requests
+	// are issued, e.g. to provide a pre-authenticated session cookie. Only
+	// used when UseCookieJar is true. The cookies are set for the URL of
+	// Request, or of the Scenario's first request when Request is nil; when
+	// neither is set, the jars start empty.
+	InitialCookies []*http.Cookie
+
+	// Validate, if set, is run against every response whose transport err
+	// is nil, with up to CaptureBodyBytes of its body. A non-nil return
+	// counts the request as a validation failure, tallied separately from
+	// transport errors, e.g. to treat a 200 response with an HTML error
+	// page as a failure. ValidateStatus, ValidateContains and
+	// ValidateJSONPath cover common cases.
+	Validate func(*http.Response, []byte) error
+
+	// CaptureBodyBytes controls how much of each response body is read
+	// into memory and handed to Validate. Zero (the default) captures
+	// nothing and the body is discarded as before; a negative value
+	// captures the full body. Has no effect unless Validate is set.
+	CaptureBodyBytes int
+
 	// Writer is where results will be written. If nil, results are written to stdout.
 	Writer io.Writer
 
@@ -108,6 +255,57 @@ type Work struct {
 	start    time.Duration
 
 	report *report
+
+	// latencyHist tracks coordinated-omission-corrected latency for the
+	// open arrival models, bucketed logarithmically so that p99.9/p99.99
+	// stay accurate without keeping every sample in memory.
+	latencyHist *histogram
+	histMu      sync.Mutex
+
+	// h2Transport is the *http2.Transport underlying the shared
+	// *http.Transport when H2 is set; H2OneConnPerWorker dials additional
+	// connections directly from it.
+	h2Transport *http2.Transport
+
+	// connsNew/connsReused count GotConnInfo.Reused outcomes across all
+	// requests, used to print a per-connection stream-utilization summary
+	// for HTTP/2 runs.
+	connsNew    int64
+	connsReused int64
+
+	// scenarioStats tallies requests and errors per scenario step name, for
+	// the per-endpoint breakdown printed when Scenario is set.
+	scenarioStats map[string]*scenarioStat
+	scenarioMu    sync.Mutex
+
+	// targetOnce/targetReq/targetErr cache the sample request used to learn
+	// the single host every worker's connections are dialed to, when
+	// Request is nil and Scenario stands in for it; see targetRequest.
+	targetOnce sync.Once
+	targetReq  *http.Request
+	targetErr  error
+
+	// validationTotal counts every request where Validate returned a
+	// non-nil error. validationSamples holds up to maxValidationSamples
+	// unique failure messages (with their own counts) for the summary
+	// printed at the end of the run.
+	validationTotal   int64
+	validationSamples map[string]int64
+	validationMu      sync.Mutex
+}
+
+// maxValidationSamples bounds how many distinct validation failure messages
+// are kept for the end-of-run summary.
+const maxValidationSamples = 10
+
+// bodyBufPool reuses response-body capture buffers across requests, since
+// CaptureBodyBytes is typically enabled for every request in a run.
+var bodyBufPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// scenarioStat tallies one scenario step's outcomes.
+type scenarioStat struct {
+	count    int64
+	errCount int64
 }
 
 func (b *Work) writer() io.Writer {
@@ -121,9 +319,46 @@ func (b *Work) writer() io.Writer {
 func (b *Work) Init() {
 	b.initOnce.Do(func() {
 		b.results = make(chan *result, min(b.C*1000, maxResult))
+		if b.Arrival != Closed {
+			b.latencyHist = newHistogram(time.Microsecond, 60*time.Second, 3)
+		}
+		if b.Scenario != nil {
+			b.scenarioStats = make(map[string]*scenarioStat)
+		}
+		if b.Validate != nil {
+			b.validationSamples = make(map[string]int64)
+		}
 	})
 }
 
+// recordScenarioResult tallies one scenario step's outcome for the
+// per-endpoint breakdown printed when Scenario is set.
+func (b *Work) recordScenarioResult(name string, err error) {
+	b.scenarioMu.Lock()
+	s, ok := b.scenarioStats[name]
+	if !ok {
+		s = &scenarioStat{}
+		b.scenarioStats[name] = s
+	}
+	s.count++
+	if err != nil {
+		s.errCount++
+	}
+	b.scenarioMu.Unlock()
+}
+
+// recordValidation tallies a Validate failure, keeping up to
+// maxValidationSamples distinct failure messages for the end-of-run summary.
+func (b *Work) recordValidation(err error) {
+	atomic.AddInt64(&b.validationTotal, 1)
+	msg := err.Error()
+	b.validationMu.Lock()
+	if _, ok := b.validationSamples[msg]; ok || len(b.validationSamples) < maxValidationSamples {
+		b.validationSamples[msg]++
+	}
+	b.validationMu.Unlock()
+}
+
 // Run makes all the requests, prints the summary. It blocks until
 // all work is done.
 func (b *Work) Run(ctx context.Context) {
@@ -139,7 +374,11 @@ func (b *Work) Run(ctx context.Context) {
 	go func() {
 		runReporter(b.report)
 	}()
-	b.runWorkers(ctx)
+	if b.Arrival != Closed {
+		b.runOpen(ctx)
+	} else {
+		b.runWorkers(ctx)
+	}
 	cancel()
 	fmt.Printf("\r%s", mansi.LineEraseToEnd+mansi.ResetColor+mansi.CursorShow)
 	b.finish()
@@ -151,18 +390,113 @@ func (b *Work) finish() {
 	// Wait until the reporter is done.
 	<-b.report.done
 	b.report.finalize(total)
+	if b.latencyHist != nil {
+		b.printCorrectedLatency()
+	}
+	if b.H2 {
+		b.printH2ConnUtilization()
+	}
+	if b.scenarioStats != nil {
+		b.printScenarioBreakdown()
+	}
+	if b.Validate != nil {
+		b.printValidationSummary()
+	}
+}
+
+// printValidationSummary prints the total count of Validate failures and a
+// bounded sample of distinct failure messages, so "a 200 with an HTML error
+// page" shows up as a counted failure with a readable reason rather than
+// silently passing as a successful request.
+func (b *Work) printValidationSummary() {
+	total := atomic.LoadInt64(&b.validationTotal)
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(b.writer(), "\nValidation failures: %d\n", total)
+	b.validationMu.Lock()
+	defer b.validationMu.Unlock()
+	for msg, count := range b.validationSamples {
+		fmt.Fprintf(b.writer(), "  [%d] %s\n", count, msg)
+	}
+}
+
+// printScenarioBreakdown prints request and error counts per scenario step
+// name, so a weighted mix or HAR replay's error behavior can be read per
+// endpoint rather than only in aggregate. Latency is not broken down per
+// step here.
+func (b *Work) printScenarioBreakdown() {
+	b.scenarioMu.Lock()
+	defer b.scenarioMu.Unlock()
+	if len(b.scenarioStats) == 0 {
+		return
+	}
+	fmt.Fprintf(b.writer(), "\nPer-endpoint breakdown:\n")
+	for name, s := range b.scenarioStats {
+		fmt.Fprintf(b.writer(), "  %-40s %8d requests, %8d errors\n", name, s.count, s.errCount)
+	}
+}
+
+// printH2ConnUtilization prints how many requests landed on a freshly
+// dialed connection versus an existing one. A low reuse ratio with
+// H2OneConnPerWorker set means the run is mostly measuring connection setup
+// rather than per-connection stream contention; a low ratio with it unset
+// means the shared pool is spreading load across more connections than
+// expected.
+func (b *Work) printH2ConnUtilization() {
+	newConns := atomic.LoadInt64(&b.connsNew)
+	reused := atomic.LoadInt64(&b.connsReused)
+	total := newConns + reused
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(b.writer(), "\nHTTP/2 connection utilization:\n")
+	fmt.Fprintf(b.writer(), "  %d new, %d reused (%.1f%% reused)\n", newConns, reused, 100*float64(reused)/float64(total))
+}
+
+// printCorrectedLatency prints percentiles computed from latencyHist, i.e.
+// latency measured against each request's intended (scheduled) start time
+// rather than when a worker actually picked it up. Under the open arrival
+// models this is the number that matters: the closed-model summary printed
+// by report hides queueing delay introduced once the server falls behind.
+func (b *Work) printCorrectedLatency() {
+	fmt.Fprintf(b.writer(), "\nLatency distribution (corrected for coordinated omission):\n")
+	for _, p := range []float64{50, 75, 90, 99, 99.9, 99.99} {
+		fmt.Fprintf(b.writer(), "  %6.2f%% in %s\n", p, b.latencyHist.valueAtPercentile(p))
+	}
 }
 
 func (b *Work) makeRequest(ctx context.Context, c *http.Client) {
+	b.makeRequestAt(ctx, c, 0)
+}
+
+// makeRequestAt issues one request on client c. intendedStart is the
+// schedule-relative time this request was supposed to start; it is only
+// meaningful under the open arrival models (PoissonOpen/ConstantOpen). When
+// zero, the actual start time is used instead, which reduces to the
+// original closed-model behavior.
+func (b *Work) makeRequestAt(ctx context.Context, c *http.Client, intendedStart time.Duration) {
 	var size int64
 	var code int
 	var dnsStart, connStart, resStart, reqStart, delayStart time.Duration
 	var dnsDuration, connDuration, resDuration, reqDuration, delayDuration time.Duration
+	var tlsStart, continueStart time.Duration
+	var tlsDuration, continueDuration time.Duration
 	var mu sync.Mutex
 	var req *http.Request
-	if b.RequestFunc != nil {
+	var stepName string
+	switch {
+	case b.Scenario != nil:
+		var err error
+		req, err = b.Scenario.Next(ctx)
+		if err != nil {
+			b.results <- &result{err: err, offset: now(), scheduledOffset: intendedStart}
+			return
+		}
+		stepName, _ = scenario.StepName(req.Context())
+	case b.RequestFunc != nil:
 		req = b.RequestFunc()
-	} else {
+	default:
 		req = cloneRequest(b.Request, b.RequestBody)
 	}
 	trace := &httptrace.ClientTrace{
@@ -180,9 +514,28 @@ func (b *Work) makeRequest(ctx context.Context, c *http.Client) {
 		GotConn: func(connInfo httptrace.GotConnInfo) {
 			if !connInfo.Reused {
 				connDuration = now() - connStart
+				atomic.AddInt64(&b.connsNew, 1)
+			} else {
+				atomic.AddInt64(&b.connsReused, 1)
 			}
 			reqStart = now()
 		},
+		TLSHandshakeStart: func() {
+			tlsStart = now()
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			mu.Lock()
+			tlsDuration = now() - tlsStart
+			mu.Unlock()
+		},
+		Wait100Continue: func() {
+			continueStart = now()
+		},
+		Got100Continue: func() {
+			mu.Lock()
+			continueDuration = now() - continueStart
+			mu.Unlock()
+		},
 		WroteRequest: func(w httptrace.WroteRequestInfo) {
 			t := now()
 			reqDuration = t - reqStart
@@ -200,43 +553,82 @@ func (b *Work) makeRequest(ctx context.Context, c *http.Client) {
 	}
 	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 	s := now()
+	if intendedStart == 0 {
+		intendedStart = s
+	}
+	var validationErr error
 	resp, err := c.Do(req)
 	if err == nil {
 		code = resp.StatusCode
-		size, _ = io.Copy(ioutil.Discard, resp.Body)
+		if b.CaptureBodyBytes != 0 && b.Validate != nil {
+			limit := int64(b.CaptureBodyBytes)
+			if b.CaptureBodyBytes < 0 {
+				limit = math.MaxInt64
+			}
+			buf := bodyBufPool.Get().(*bytes.Buffer)
+			buf.Reset()
+			captured, _ := io.Copy(buf, io.LimitReader(resp.Body, limit))
+			// Drain anything past the capture limit so the connection can
+			// still be returned to the pool for reuse.
+			rest, _ := io.Copy(ioutil.Discard, resp.Body)
+			size = captured + rest
+			if b.Validate != nil {
+				validationErr = b.Validate(resp, buf.Bytes())
+			}
+			bodyBufPool.Put(buf)
+		} else {
+			size, _ = io.Copy(ioutil.Discard, resp.Body)
+			if b.Validate != nil {
+				validationErr = b.Validate(resp, nil)
+			}
+		}
 		resp.Body.Close()
 	}
+	if validationErr != nil {
+		b.recordValidation(validationErr)
+	}
 	t := now()
 	resDuration = t - resStart
-	finish := t - s
+	// finish is latency corrected for coordinated omission: under the open
+	// arrival models it is measured from when the request was scheduled to
+	// start, not from when a worker actually picked it up.
+	finish := t - intendedStart
+	if b.latencyHist != nil {
+		b.histMu.Lock()
+		b.latencyHist.record(finish)
+		b.histMu.Unlock()
+	}
 	mu.Lock()
 	b.results <- &result{
-		offset:        s,
-		statusCode:    code,
-		duration:      finish,
-		err:           err,
-		contentLength: size,
-		connDuration:  connDuration,
-		dnsDuration:   dnsDuration,
-		reqDuration:   reqDuration,
-		resDuration:   resDuration,
-		delayDuration: delayDuration,
+		offset:           s,
+		scheduledOffset:  intendedStart,
+		statusCode:       code,
+		duration:         finish,
+		err:              err,
+		contentLength:    size,
+		connDuration:     connDuration,
+		dnsDuration:      dnsDuration,
+		reqDuration:      reqDuration,
+		resDuration:      resDuration,
+		delayDuration:    delayDuration,
+		tlsDuration:      tlsDuration,
+		continueDuration: continueDuration,
+		stepName:         stepName,
+		validationErr:    validationErr,
+	}
+	if b.scenarioStats != nil {
+		b.recordScenarioResult(stepName, err)
 	}
 	mu.Unlock()
 }
 
-func (b *Work) runWorker(ctx context.Context, client *http.Client, n int) {
+func (b *Work) runWorker(ctx context.Context, client *http.Client, n int, workerID int) {
 	var throttle <-chan time.Time
 	if b.QPS > 0 {
 		throttle = time.Tick(time.Duration(1e6/(b.QPS)) * time.Microsecond)
 	}
 
-	if b.DisableRedirects {
-		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		}
-	}
-
+	ctx = scenario.WithWorkerID(ctx, workerID)
 	for i := 0; i < n; i++ {
 		// Check if application is stopped. Do not send into a closed channel.
 		select {
@@ -246,63 +638,334 @@ func (b *Work) runWorker(ctx context.Context, client *http.Client, n int) {
 			if b.QPS > 0 {
 				<-throttle
 			}
-			b.makeRequest(ctx, client)
+			b.makeRequest(scenario.WithIter(ctx, i), client)
 		}
 	}
 }
 
-func (b *Work) runWorkers(ctx context.Context) {
-	var wg sync.WaitGroup
-	wg.Add(b.C)
+// Defaults mirrored from http.DefaultTransport, used whenever the
+// corresponding Work field is left at its zero value.
+const (
+	defaultDialTimeout           = 30 * time.Second
+	defaultKeepAlive             = 30 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultExpectContinueTimeout = 1 * time.Second
+	defaultIdleConnTimeout       = 90 * time.Second
+)
 
-	hostName, _, err := net.SplitHostPort(b.Request.Host)
-	if err != nil {
-		hostName = b.Request.Host
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
 	}
+	return d
+}
 
-	var tlsCache tls.ClientSessionCache
+// targetRequest returns a sample request identifying the single host every
+// worker's connections are dialed to: newTransport's TLS ServerName and
+// dialH2Conn's dedicated dial address both need this fixed before any
+// worker starts. When Request is set it's used directly; with only Scenario
+// set, the scenario's first Next call stands in for it, since that's the
+// only request requester.go can get its hands on before workers start. The
+// result is cached so Scenario.Next is sampled for this purpose at most
+// once, however many workers dial their own connection.
+func (b *Work) targetRequest() (*http.Request, error) {
+	b.targetOnce.Do(func() {
+		if b.Request != nil {
+			b.targetReq = b.Request
+			return
+		}
+		if b.Scenario == nil {
+			b.targetErr = fmt.Errorf("requester: Work has neither Request nor Scenario set")
+			return
+		}
+		req, err := b.Scenario.Next(context.Background())
+		if err != nil {
+			b.targetErr = fmt.Errorf("requester: resolving target host from Scenario: %w", err)
+			return
+		}
+		b.targetReq = req
+	})
+	return b.targetReq, b.targetErr
+}
 
+// newTransport builds the shared *http.Transport used by every worker's
+// *http.Client, whichever arrival model is in effect.
+func (b *Work) newTransport() *http.Transport {
+	var hostName string
+	if target, err := b.targetRequest(); err == nil {
+		hostName, _, err = net.SplitHostPort(target.Host)
+		if err != nil {
+			hostName = target.Host
+		}
+	}
+
+	var tlsCache tls.ClientSessionCache
 	if b.TLSResume {
 		tlsCache = tls.NewLRUClientSessionCache(1) // we only have one target
 	}
 
+	dialContext := b.DialContext
+	if dialContext == nil {
+		dialContext = (&net.Dialer{
+			Timeout:   durationOrDefault(b.DialTimeout, defaultDialTimeout),
+			KeepAlive: durationOrDefault(b.KeepAlive, defaultKeepAlive),
+		}).DialContext
+	}
+
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: b.K,
 			ServerName:         hostName,
 			ClientSessionCache: tlsCache,
 		},
-		MaxIdleConnsPerHost: min(b.C, maxIdleConn),
-		DisableCompression:  b.DisableCompression,
-		DisableKeepAlives:   b.DisableKeepAlives,
-		Proxy:               http.ProxyURL(b.ProxyAddr),
+		MaxIdleConnsPerHost:   min(b.C, maxIdleConn),
+		DisableCompression:    b.DisableCompression,
+		DisableKeepAlives:     b.DisableKeepAlives,
+		Proxy:                 http.ProxyURL(b.ProxyAddr),
+		DialContext:           dialContext,
+		TLSHandshakeTimeout:   durationOrDefault(b.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ExpectContinueTimeout: durationOrDefault(b.ExpectContinueTimeout, defaultExpectContinueTimeout),
+		ResponseHeaderTimeout: b.ResponseHeaderTimeout,
+		IdleConnTimeout:       durationOrDefault(b.IdleConnTimeout, defaultIdleConnTimeout),
 	}
 	if b.H2 {
-		http2.ConfigureTransport(tr)
+		h2tr, err := http2.ConfigureTransports(tr)
+		if err == nil {
+			h2tr.ReadIdleTimeout = b.H2ReadIdleTimeout
+			h2tr.PingTimeout = b.H2PingTimeout
+			h2tr.StrictMaxConcurrentStreams = b.H2StrictMaxStreams
+			h2tr.MaxHeaderListSize = b.H2MaxHeaderListSize
+			h2tr.MaxReadFrameSize = b.H2MaxReadFrameSize
+			b.h2Transport = h2tr
+		}
 	} else {
 		tr.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
 	}
-	client := &http.Client{Transport: tr, Timeout: time.Duration(b.Timeout) * time.Second}
+	return tr
+}
+
+// newClient builds a worker's *http.Client on top of the shared transport tr.
+func (b *Work) newClient(tr *http.Transport) *http.Client {
+	c := &http.Client{Transport: tr, Timeout: time.Duration(b.Timeout) * time.Second}
+	if b.UseCookieJar {
+		// Each worker gets its own jar so a single misbehaving worker's
+		// cookies can't poison the rest of the test.
+		jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		if len(b.InitialCookies) > 0 {
+			if target, err := b.targetRequest(); err == nil {
+				jar.SetCookies(target.URL, b.InitialCookies)
+			}
+		}
+		c.Jar = jar
+	}
+	if b.DisableRedirects {
+		c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return c
+}
+
+func (b *Work) runWorkers(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(b.C)
+
+	tr := b.newTransport()
+
+	startWorker := func(n, workerID int) {
+		defer wg.Done()
+		if b.H2 && b.H2OneConnPerWorker && b.h2Transport != nil {
+			cc, err := b.dialH2Conn(ctx)
+			if err != nil {
+				// Fall back to the shared pool rather than losing the worker's
+				// share of the run.
+				b.runWorker(ctx, b.newClient(tr), n, workerID)
+				return
+			}
+			defer cc.Close()
+			b.runH2Worker(ctx, b.newClient(tr), cc, n, workerID)
+			return
+		}
+		b.runWorker(ctx, b.newClient(tr), n, workerID)
+	}
 
 	// Ignore the case where b.N % b.C != 0.
 	left := b.N
 	for i := 0; i < b.C-1; i++ {
 		n := left / (b.C - i)
 		left = left - n
-		go func(n int) {
-			b.runWorker(ctx, client, n)
-			wg.Done()
-		}(n)
+		go startWorker(n, i)
 	}
 
-	go func(n int) {
-		b.runWorker(ctx, client, n)
-		wg.Done()
-	}(left)
+	go startWorker(left, b.C-1)
 
 	wg.Wait()
 }
 
+// dialH2Conn dials a single dedicated HTTP/2 connection to the target host
+// (see targetRequest), bypassing b.h2Transport's connection pool, for use
+// with H2OneConnPerWorker.
+func (b *Work) dialH2Conn(ctx context.Context) (*http2.ClientConn, error) {
+	target, err := b.targetRequest()
+	if err != nil {
+		return nil, err
+	}
+	addr := target.URL.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+	hostName, _, _ := net.SplitHostPort(addr)
+	conn, err := (&tls.Dialer{
+		Config: &tls.Config{
+			InsecureSkipVerify: b.K,
+			ServerName:         hostName,
+			NextProtos:         []string{"h2"},
+		},
+	}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return b.h2Transport.NewClientConn(conn)
+}
+
+// roundTripperFunc adapts a plain RoundTrip-shaped function, such as
+// (*http2.ClientConn).RoundTrip, to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// runH2Worker drives n requests against a single dedicated HTTP/2
+// connection, issuing up to H2MaxConcurrentStreams of them concurrently so
+// that requests genuinely contend for that connection's stream budget
+// instead of each waiting its turn - the only way to observe per-connection
+// head-of-line blocking.
+func (b *Work) runH2Worker(ctx context.Context, client *http.Client, cc *http2.ClientConn, n int, workerID int) {
+	// (*http2.ClientConn).RoundTrip never fires httptrace's GetConn/GotConn
+	// callbacks - those are only invoked by (*http2.Transport).RoundTripOpt -
+	// so makeRequestAt's reqStart/connsNew/connsReused bookkeeping has to be
+	// driven by hand here instead.
+	var dialed int32
+	client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if trace := httptrace.ContextClientTrace(req.Context()); trace != nil {
+			if trace.GetConn != nil {
+				trace.GetConn(req.Host)
+			}
+			if trace.GotConn != nil {
+				reused := !atomic.CompareAndSwapInt32(&dialed, 0, 1)
+				trace.GotConn(httptrace.GotConnInfo{Reused: reused})
+			}
+		}
+		return cc.RoundTrip(req)
+	})
+	ctx = scenario.WithWorkerID(ctx, workerID)
+
+	concurrency := int(b.H2MaxConcurrentStreams)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var throttle <-chan time.Time
+	if b.QPS > 0 {
+		throttle = time.Tick(time.Duration(1e6/(b.QPS)) * time.Microsecond)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var inFlight sync.WaitGroup
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break loop
+		default:
+		}
+		if b.QPS > 0 {
+			<-throttle
+		}
+		sem <- struct{}{}
+		inFlight.Add(1)
+		go func(iter int) {
+			defer inFlight.Done()
+			defer func() { <-sem }()
+			b.makeRequest(scenario.WithIter(ctx, iter), client)
+		}(i)
+	}
+	inFlight.Wait()
+}
+
+// runOpen drives the PoissonOpen/ConstantOpen arrival models: a single
+// generator schedules request start times at inter-arrival intervals drawn
+// from QPS, then dispatches each onto a fixed pool of C workers over a
+// channel. A request whose turn comes up while every worker is still busy
+// is not dropped or delayed in the schedule: its intended start time travels
+// with it, so makeRequestAt can still report latency relative to when it
+// was supposed to start rather than when a worker became free.
+func (b *Work) runOpen(ctx context.Context) {
+	tr := b.newTransport()
+
+	jobs := make(chan time.Duration, b.C)
+	var wg sync.WaitGroup
+	wg.Add(b.C)
+	for i := 0; i < b.C; i++ {
+		client := b.newClient(tr)
+		workerCtx := scenario.WithWorkerID(ctx, i)
+		go func(c *http.Client, ctx context.Context) {
+			defer wg.Done()
+			iter := 0
+			for intendedStart := range jobs {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					b.makeRequestAt(scenario.WithIter(ctx, iter), c, intendedStart)
+					iter++
+				}
+			}
+		}(client, workerCtx)
+	}
+
+	rnd := rand.New(rand.NewSource(int64(now())))
+	next := now()
+	for i := 0; i < b.N; i++ {
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return
+		default:
+		}
+
+		var interArrival time.Duration
+		if b.Arrival == PoissonOpen {
+			u := rnd.Float64()
+			interArrival = time.Duration(-math.Log(1-u) / b.QPS * float64(time.Second))
+		} else {
+			interArrival = time.Duration(float64(time.Second) / b.QPS)
+		}
+		next += interArrival
+
+		if sleepFor := next - now(); sleepFor > 0 {
+			t := time.NewTimer(sleepFor)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				close(jobs)
+				wg.Wait()
+				return
+			case <-t.C:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return
+		case jobs <- next:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
 // cloneRequest returns a clone of the provided *http.Request.
 // The clone is a shallow copy of the struct and its Header map.
 func cloneRequest(r *http.Request, body []byte) *http.Request {
@@ -0,0 +1,124 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requester
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ValidateStatus builds a Validate func that fails unless the response's
+// status code is one of codes.
+func ValidateStatus(codes ...int) func(*http.Response, []byte) error {
+	return func(resp *http.Response, body []byte) error {
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected status %d, want one of %v", resp.StatusCode, codes)
+	}
+}
+
+// ValidateContains builds a Validate func that fails unless the captured
+// response body contains substr. It requires Work.CaptureBodyBytes to be
+// non-zero; with no captured body it always fails.
+func ValidateContains(substr string) func(*http.Response, []byte) error {
+	return func(resp *http.Response, body []byte) error {
+		if !bytes.Contains(body, []byte(substr)) {
+			return fmt.Errorf("response body does not contain %q", substr)
+		}
+		return nil
+	}
+}
+
+// ValidateJSONPath builds a Validate func that parses the captured response
+// body as JSON, resolves path as a JSON Pointer (RFC 6901, e.g.
+// "/data/id"), and fails unless the value at that path stringifies to
+// expected.
+func ValidateJSONPath(path, expected string) func(*http.Response, []byte) error {
+	return func(resp *http.Response, body []byte) error {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fmt.Errorf("validating %s: invalid JSON body: %w", path, err)
+		}
+		got, err := jsonPointerLookup(doc, path)
+		if err != nil {
+			return fmt.Errorf("validating %s: %w", path, err)
+		}
+		if gotStr := jsonValueToString(got); gotStr != expected {
+			return fmt.Errorf("%s = %q, want %q", path, gotStr, expected)
+		}
+		return nil
+	}
+}
+
+// jsonPointerLookup resolves a JSON Pointer (RFC 6901) against a value
+// produced by encoding/json (map[string]interface{}, []interface{}, or a
+// scalar). An empty path refers to the whole document.
+func jsonPointerLookup(doc interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return doc, nil
+	}
+	if path[0] != '/' {
+		return nil, fmt.Errorf("pointer %q must start with '/'", path)
+	}
+
+	cur := doc
+	for _, tok := range strings.Split(path[1:], "/") {
+		tok = strings.ReplaceAll(tok, "~1", "/")
+		tok = strings.ReplaceAll(tok, "~0", "~")
+
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			val, ok := v[tok]
+			if !ok {
+				return nil, fmt.Errorf("no member %q", tok)
+			}
+			cur = val
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("no element %q", tok)
+			}
+			cur = v[i]
+		default:
+			return nil, fmt.Errorf("cannot descend into %T at %q", cur, tok)
+		}
+	}
+	return cur, nil
+}
+
+// jsonValueToString renders a decoded JSON value the way a human would
+// write it in a comparison, without the quoting encoding/json would add.
+func jsonValueToString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
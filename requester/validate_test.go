@@ -0,0 +1,77 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requester
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestValidateStatus(t *testing.T) {
+	v := ValidateStatus(200, 201)
+	if err := v(&http.Response{StatusCode: 200}, nil); err != nil {
+		t.Errorf("200 with [200,201]: unexpected error %v", err)
+	}
+	if err := v(&http.Response{StatusCode: 404}, nil); err == nil {
+		t.Error("404 with [200,201]: expected error, got nil")
+	}
+}
+
+func TestValidateContains(t *testing.T) {
+	v := ValidateContains("ok")
+	if err := v(&http.Response{}, []byte("all ok here")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := v(&http.Response{}, []byte("nope")); err == nil {
+		t.Error("expected error when substring is absent, got nil")
+	}
+}
+
+func TestValidateJSONPath(t *testing.T) {
+	body := []byte(`{"data":{"id":"42","tags":["a","b"]},"ok":true}`)
+	cases := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"/data/id", "42", false},
+		{"/data/tags/1", "b", false},
+		{"/ok", "true", false},
+		{"/missing", "", true},
+		{"no-leading-slash", "", true},
+	}
+	for _, c := range cases {
+		v := ValidateJSONPath(c.path, c.want)
+		err := v(&http.Response{}, body)
+		if c.wantErr && err == nil {
+			t.Errorf("path %q: expected error, got nil", c.path)
+		}
+		if !c.wantErr && err != nil {
+			t.Errorf("path %q: unexpected error %v", c.path, err)
+		}
+	}
+}
+
+func TestJSONPointerLookupEscaping(t *testing.T) {
+	doc := map[string]interface{}{"a/b": "slash", "c~d": "tilde"}
+	got, err := jsonPointerLookup(doc, "/a~1b")
+	if err != nil || got != "slash" {
+		t.Errorf("lookup(/a~1b) = %v, %v, want \"slash\", nil", got, err)
+	}
+	got, err = jsonPointerLookup(doc, "/c~0d")
+	if err != nil || got != "tilde" {
+		t.Errorf("lookup(/c~0d) = %v, %v, want \"tilde\", nil", got, err)
+	}
+}
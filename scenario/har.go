@@ -0,0 +1,188 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplayMode selects the order in which HARReplay steps through a HAR
+// file's entries.
+type ReplayMode int
+
+const (
+	// Sequential replays entries in the order they appear in the HAR file,
+	// wrapping around once the end is reached.
+	Sequential ReplayMode = iota
+
+	// RandomOrder replays entries in a uniformly random order.
+	RandomOrder
+
+	// Timed replays entries in order, pacing them to preserve the original
+	// inter-arrival timing recorded in each entry's startedDateTime. Pacing
+	// covers the first pass through the file; once replay wraps around past
+	// the last entry, entries are sent without delay.
+	Timed
+)
+
+// harFile mirrors the subset of the HAR 1.2 schema this package reads.
+// See http://www.softwareishard.com/blog/har-12-spec/.
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime string `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	name    string
+	method  string
+	url     string
+	headers http.Header
+	body    string
+	started time.Time
+}
+
+// HARReplay replays the requests recorded in a HAR 1.2 file, either
+// sequentially, in random order, or paced to preserve the original
+// inter-arrival timing between entries.
+type HARReplay struct {
+	entries []harEntry
+	mode    ReplayMode
+	rndMu   sync.Mutex
+	rnd     *rand.Rand
+
+	next int64 // atomic index into entries, used by Sequential
+
+	pacingOnce sync.Once
+	base       time.Time // first entry's startedDateTime
+	start      time.Time // wall-clock time of the first Next call, used by Timed
+}
+
+// LoadHAR parses the HAR 1.2 file at path and returns a scenario that
+// replays its requests according to mode.
+func LoadHAR(path string, mode ReplayMode, seed int64) (*HARReplay, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("scenario: reading HAR file: %w", err)
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("scenario: parsing HAR file: %w", err)
+	}
+	if len(har.Log.Entries) == 0 {
+		return nil, fmt.Errorf("scenario: HAR file %s has no entries", path)
+	}
+
+	entries := make([]harEntry, 0, len(har.Log.Entries))
+	for _, e := range har.Log.Entries {
+		started, err := time.Parse(time.RFC3339Nano, e.StartedDateTime)
+		if err != nil {
+			return nil, fmt.Errorf("scenario: entry %q: parsing startedDateTime: %w", e.Request.URL, err)
+		}
+		headers := make(http.Header, len(e.Request.Headers))
+		for _, h := range e.Request.Headers {
+			headers.Add(h.Name, h.Value)
+		}
+		var body string
+		if e.Request.PostData != nil {
+			body = e.Request.PostData.Text
+		}
+		entries = append(entries, harEntry{
+			name:    e.Request.Method + " " + e.Request.URL,
+			method:  e.Request.Method,
+			url:     e.Request.URL,
+			headers: headers,
+			body:    body,
+			started: started,
+		})
+	}
+
+	return &HARReplay{
+		entries: entries,
+		mode:    mode,
+		rnd:     rand.New(rand.NewSource(seed)),
+		base:    entries[0].started,
+	}, nil
+}
+
+// Next returns the next request according to r's ReplayMode. Under Timed,
+// Next blocks until the entry's offset from the first entry's
+// startedDateTime has elapsed since the first Next call.
+func (r *HARReplay) Next(ctx context.Context) (*http.Request, error) {
+	entry := r.pick()
+
+	if r.mode == Timed {
+		r.pacingOnce.Do(func() { r.start = time.Now() })
+		wait := r.start.Add(entry.started.Sub(r.base)).Sub(time.Now())
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, entry.method, entry.url, bytes.NewReader([]byte(entry.body)))
+	if err != nil {
+		return nil, fmt.Errorf("scenario: building request for %q: %w", entry.name, err)
+	}
+	req.Header = entry.headers.Clone()
+
+	req, err = applyPlaceholders(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("scenario: entry %q: %w", entry.name, err)
+	}
+	req = req.WithContext(WithStepName(req.Context(), entry.name))
+	return req, nil
+}
+
+func (r *HARReplay) pick() harEntry {
+	switch r.mode {
+	case RandomOrder:
+		r.rndMu.Lock()
+		i := r.rnd.Intn(len(r.entries))
+		r.rndMu.Unlock()
+		return r.entries[i]
+	default:
+		i := atomic.AddInt64(&r.next, 1) - 1
+		return r.entries[int(i)%len(r.entries)]
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const sampleHAR = `{
+  "log": {
+    "entries": [
+      {
+        "startedDateTime": "2024-01-01T00:00:00.000Z",
+        "request": {
+          "method": "GET",
+          "url": "http://example.com/a",
+          "headers": [{"name": "X-Test", "value": "1"}]
+        }
+      },
+      {
+        "startedDateTime": "2024-01-01T00:00:00.100Z",
+        "request": {
+          "method": "POST",
+          "url": "http://example.com/b",
+          "postData": {"mimeType": "text/plain", "text": "hello"}
+        }
+      }
+    ]
+  }
+}`
+
+func writeSampleHAR(t *testing.T) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "sample-*.har")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	if _, err := f.WriteString(sampleHAR); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
+
+func TestLoadHARSequential(t *testing.T) {
+	replay, err := LoadHAR(writeSampleHAR(t), Sequential, 1)
+	if err != nil {
+		t.Fatalf("LoadHAR: %v", err)
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		req, err := replay.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, req.Method+" "+req.URL.Path)
+	}
+	want := []string{"GET /a", "POST /b", "GET /a", "POST /b"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadHARRandomOrder(t *testing.T) {
+	replay, err := LoadHAR(writeSampleHAR(t), RandomOrder, 2)
+	if err != nil {
+		t.Fatalf("LoadHAR: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := replay.Next(context.Background()); err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+	}
+}
+
+func TestLoadHARTimedPacing(t *testing.T) {
+	replay, err := LoadHAR(writeSampleHAR(t), Timed, 1)
+	if err != nil {
+		t.Fatalf("LoadHAR: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := replay.Next(context.Background()); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if _, err := replay.Next(context.Background()); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("two entries 100ms apart replayed in %v, want >= 80ms", elapsed)
+	}
+}
+
+func TestLoadHARErrors(t *testing.T) {
+	if _, err := LoadHAR("/no/such/file.har", Sequential, 1); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+	f, err := ioutil.TempFile("", "empty-*.har")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"log":{"entries":[]}}`)
+	f.Close()
+	if _, err := LoadHAR(f.Name(), Sequential, 1); err == nil {
+		t.Error("expected error for HAR with no entries, got nil")
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scenario lets a load test drive a realistic mix of endpoints, or
+// replay a captured HAR file, instead of a single request/RequestFunc.
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Scenario produces the next request to send. Implementations are called
+// concurrently from many workers and must be safe for concurrent use.
+type Scenario interface {
+	Next(ctx context.Context) (*http.Request, error)
+}
+
+type stepNameKey struct{}
+
+// WithStepName attaches a scenario step name to ctx, so that it can be
+// recovered with StepName once the request has been built.
+func WithStepName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, stepNameKey{}, name)
+}
+
+// StepName returns the scenario step name attached to ctx, if any.
+func StepName(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(stepNameKey{}).(string)
+	return name, ok
+}
+
+type workerIDKey struct{}
+type iterKey struct{}
+
+// WithWorkerID attaches the id of the worker issuing the next request, for
+// use by the "{{.WorkerID}}" placeholder.
+func WithWorkerID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, workerIDKey{}, id)
+}
+
+// WithIter attaches the 0-based iteration count of the worker issuing the
+// next request, for use by the "{{.Iter}}" placeholder.
+func WithIter(ctx context.Context, iter int) context.Context {
+	return context.WithValue(ctx, iterKey{}, iter)
+}
+
+// placeholderData is the dot-value available to templates substituted into
+// scenario URLs and bodies.
+type placeholderData struct {
+	WorkerID int
+	Iter     int
+}
+
+var placeholderFuncs = template.FuncMap{
+	"env": os.Getenv,
+}
+
+// renderPlaceholders substitutes "{{.WorkerID}}", "{{.Iter}}" and
+// "{{env \"VAR\"}}" in s using values taken from ctx, so a single
+// WeightedMix or HAR definition can drive many workers without collisions.
+func renderPlaceholders(ctx context.Context, s string) (string, error) {
+	tmpl, err := template.New("scenario").Funcs(placeholderFuncs).Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("scenario: parsing placeholders: %w", err)
+	}
+	workerID, _ := ctx.Value(workerIDKey{}).(int)
+	iter, _ := ctx.Value(iterKey{}).(int)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, placeholderData{WorkerID: workerID, Iter: iter}); err != nil {
+		return "", fmt.Errorf("scenario: rendering placeholders: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Step is one entry in a WeightedMix: Build is called to produce the
+// request whenever this step is selected, Weight is its relative frequency,
+// and Name tags the resulting request (and its result) for per-endpoint
+// reporting.
+type Step struct {
+	Name   string
+	Weight int
+	Build  func() *http.Request
+}
+
+// WeightedMix selects among a fixed set of request builders according to
+// their relative Weight, e.g. "70% GET /feed, 25% GET /item/{id}, 5% POST
+// /comment", using alias-method sampling so selection is O(1) regardless of
+// how many steps there are.
+type WeightedMix struct {
+	steps []Step
+	alias []int
+	prob  []float64
+	rndMu sync.Mutex
+	rnd   *rand.Rand
+}
+
+// NewWeightedMix builds a WeightedMix from steps. Weights must be positive
+// and there must be at least one step.
+func NewWeightedMix(steps []Step, seed int64) (*WeightedMix, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("scenario: WeightedMix needs at least one step")
+	}
+	total := 0
+	for _, s := range steps {
+		if s.Weight <= 0 {
+			return nil, fmt.Errorf("scenario: step %q has non-positive weight %d", s.Name, s.Weight)
+		}
+		total += s.Weight
+	}
+
+	n := len(steps)
+	prob := make([]float64, n)
+	alias := make([]int, n)
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, s := range steps {
+		scaled[i] = float64(s.Weight) * float64(n) / float64(total)
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		l := small[len(small)-1]
+		small = small[:len(small)-1]
+		g := large[len(large)-1]
+		large = large[:len(large)-1]
+		prob[l] = scaled[l]
+		alias[l] = g
+		scaled[g] = scaled[g] + scaled[l] - 1
+		if scaled[g] < 1 {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+	for _, g := range large {
+		prob[g] = 1
+	}
+	for _, l := range small {
+		prob[l] = 1
+	}
+
+	return &WeightedMix{
+		steps: steps,
+		alias: alias,
+		prob:  prob,
+		rnd:   rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// Next picks a step via alias-method sampling and builds its request. The
+// step's Name is attached to the request's context, retrievable with
+// StepName, and the request's URL and body have "{{.WorkerID}}", "{{.Iter}}"
+// and "{{env \"VAR\"}}" placeholders substituted using values from ctx.
+func (m *WeightedMix) Next(ctx context.Context) (*http.Request, error) {
+	m.rndMu.Lock()
+	i := m.rnd.Intn(len(m.steps))
+	pick := m.rnd.Float64()
+	m.rndMu.Unlock()
+	if pick >= m.prob[i] {
+		i = m.alias[i]
+	}
+	step := m.steps[i]
+	req := step.Build()
+	req, err := applyPlaceholders(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("scenario: step %q: %w", step.Name, err)
+	}
+	req = req.WithContext(WithStepName(req.Context(), step.Name))
+	return req, nil
+}
+
+// bracesUnescaper undoes the percent-encoding url.URL.String() applies to
+// "{" and "}", which are not in net/url's unreserved set. Without this, a
+// placeholder built into a request's URL (e.g. via http.NewRequest) has
+// already been turned into "%7B%7B...%7D%7D" by the time applyPlaceholders
+// sees it, and the template delimiters it's looking for are gone.
+var bracesUnescaper = strings.NewReplacer(
+	"%7B%7B", "{{", "%7b%7b", "{{",
+	"%7D%7D", "}}", "%7d%7d", "}}",
+)
+
+// applyPlaceholders renders "{{.WorkerID}}", "{{.Iter}}" and
+// "{{env \"VAR\"}}" placeholders in req's URL and body.
+func applyPlaceholders(ctx context.Context, req *http.Request) (*http.Request, error) {
+	original := req.URL.String()
+	rawURL, err := renderPlaceholders(ctx, bracesUnescaper.Replace(original))
+	if err != nil {
+		return nil, err
+	}
+	if rawURL != original {
+		u, err := req.URL.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("scenario: rendered URL %q: %w", rawURL, err)
+		}
+		req.URL = u
+		req.Host = u.Host
+	}
+
+	if req.Body == nil {
+		return req, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("scenario: reading body: %w", err)
+	}
+	rendered, err := renderPlaceholders(ctx, string(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader([]byte(rendered)))
+	req.ContentLength = int64(len(rendered))
+	return req, nil
+}
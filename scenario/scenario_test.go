@@ -0,0 +1,103 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func newStep(name string, weight int) Step {
+	return Step{
+		Name:   name,
+		Weight: weight,
+		Build: func() *http.Request {
+			req, _ := http.NewRequest("GET", "http://example.com/"+name, nil)
+			return req
+		},
+	}
+}
+
+func TestNewWeightedMixValidation(t *testing.T) {
+	if _, err := NewWeightedMix(nil, 1); err == nil {
+		t.Error("expected error with no steps, got nil")
+	}
+	if _, err := NewWeightedMix([]Step{newStep("a", 0)}, 1); err == nil {
+		t.Error("expected error with non-positive weight, got nil")
+	}
+}
+
+func TestWeightedMixDistribution(t *testing.T) {
+	steps := []Step{newStep("heavy", 90), newStep("light", 10)}
+	mix, err := NewWeightedMix(steps, 42)
+	if err != nil {
+		t.Fatalf("NewWeightedMix: %v", err)
+	}
+
+	const n = 20000
+	counts := map[string]int{}
+	for i := 0; i < n; i++ {
+		req, err := mix.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		name, _ := StepName(req.Context())
+		counts[name]++
+	}
+
+	ratio := float64(counts["heavy"]) / float64(n)
+	if ratio < 0.85 || ratio > 0.95 {
+		t.Errorf("heavy step ratio = %.3f, want close to 0.90", ratio)
+	}
+}
+
+func TestWeightedMixNextConcurrent(t *testing.T) {
+	steps := []Step{newStep("a", 1), newStep("b", 1)}
+	mix, err := NewWeightedMix(steps, 7)
+	if err != nil {
+		t.Fatalf("NewWeightedMix: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := mix.Next(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestApplyPlaceholdersInURL(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com/item/{{.WorkerID}}/{{.Iter}}", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	ctx := WithIter(WithWorkerID(context.Background(), 3), 5)
+	got, err := applyPlaceholders(ctx, req)
+	if err != nil {
+		t.Fatalf("applyPlaceholders: %v", err)
+	}
+	if want := "/item/3/5"; got.URL.Path != want {
+		t.Errorf("URL.Path = %q, want %q", got.URL.Path, want)
+	}
+}